@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	pendingBucket  = []byte("pending")
+	inFlightBucket = []byte("inflight")
+	deadBucket     = []byte("dead")
+	seenKeyBucket  = []byte("seenkeys")
+)
+
+// boltStore is the default persistent backend: a single bbolt file
+// with "pending", "inflight" and "dead" buckets keyed by
+// QueueItem.ID, plus a "seenkeys" bucket for idempotency dedup.
+type boltStore struct {
+	db *bbolt.DB
+}
+
+func newBoltStore(path string) (*boltStore, error) {
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{pendingBucket, inFlightBucket, deadBucket, seenKeyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (b *boltStore) Put(item QueueItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(pendingBucket).Put([]byte(item.ID), data)
+	})
+}
+
+func (b *boltStore) PopOldest() (*QueueItem, error) {
+	var item *QueueItem
+	now := time.Now()
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		pending := tx.Bucket(pendingBucket)
+		c := pending.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var it QueueItem
+			if err := json.Unmarshal(v, &it); err != nil {
+				return err
+			}
+			if notBefore, ok := parseRFC3339(it.NotBefore); ok && now.Before(notBefore) {
+				continue
+			}
+			if err := pending.Delete(k); err != nil {
+				return err
+			}
+			it.DeliveredAt = nowRFC3339()
+			data, err := json.Marshal(it)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(inFlightBucket).Put(k, data); err != nil {
+				return err
+			}
+			item = &it
+			return nil
+		}
+		return nil
+	})
+	return item, err
+}
+
+func (b *boltStore) Peek() ([]QueueItem, error) {
+	var items []QueueItem
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		for bucket, state := range map[string]string{
+			string(pendingBucket):  "pending",
+			string(inFlightBucket): "inflight",
+			string(deadBucket):     "dead",
+		} {
+			c := tx.Bucket([]byte(bucket)).Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				var it QueueItem
+				if err := json.Unmarshal(v, &it); err != nil {
+					return err
+				}
+				it.State = state
+				items = append(items, it)
+			}
+		}
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, err
+}
+
+func (b *boltStore) Ack(id string) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(inFlightBucket).Delete([]byte(id))
+	})
+}
+
+func (b *boltStore) Nack(id string) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		inFlight := tx.Bucket(inFlightBucket)
+		v := inFlight.Get([]byte(id))
+		if v == nil {
+			return nil
+		}
+		var item QueueItem
+		if err := json.Unmarshal(v, &item); err != nil {
+			return err
+		}
+		if err := inFlight.Delete([]byte(id)); err != nil {
+			return err
+		}
+
+		item.RetryCount++
+		if item.RetryCount > maxRetries {
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			return tx.Bucket(deadBucket).Put([]byte(id), data)
+		}
+
+		item.NotBefore = time.Now().Add(backoffDuration(item.RetryCount)).Format(time.RFC3339Nano)
+		item.DeliveredAt = ""
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(pendingBucket).Put([]byte(id), data)
+	})
+}
+
+// Requeue moves every item left in the inflight bucket back to
+// pending. Called once on open so an item stranded by the server
+// itself dying mid-delivery (not just a disconnecting consumer, which
+// already goes through Nack) is recovered instead of stuck forever.
+func (b *boltStore) Requeue() (int, error) {
+	n := 0
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		inFlight := tx.Bucket(inFlightBucket)
+		pending := tx.Bucket(pendingBucket)
+		c := inFlight.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var item QueueItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			item.DeliveredAt = ""
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if err := pending.Put(k, data); err != nil {
+				return err
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (b *boltStore) PopDead() (*QueueItem, error) {
+	var item *QueueItem
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		dead := tx.Bucket(deadBucket)
+		c := dead.Cursor()
+		k, v := c.First()
+		if k == nil {
+			return nil
+		}
+		var it QueueItem
+		if err := json.Unmarshal(v, &it); err != nil {
+			return err
+		}
+		if err := c.Delete(); err != nil {
+			return err
+		}
+		item = &it
+		return nil
+	})
+	return item, err
+}
+
+func (b *boltStore) PutDead(item QueueItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(deadBucket).Put([]byte(item.ID), data)
+	})
+}
+
+func (b *boltStore) SeenKey(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	var id string
+	var ok bool
+	b.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(seenKeyBucket).Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		var entry struct {
+			ID string    `json:"id"`
+			At time.Time `json:"at"`
+		}
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return err
+		}
+		if time.Since(entry.At) > idempotencyTTL {
+			return nil
+		}
+		id, ok = entry.ID, true
+		return nil
+	})
+	return id, ok
+}
+
+func (b *boltStore) RememberKey(key, id string) error {
+	if key == "" {
+		return nil
+	}
+	data, err := json.Marshal(struct {
+		ID string    `json:"id"`
+		At time.Time `json:"at"`
+	}{ID: id, At: time.Now()})
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(seenKeyBucket).Put([]byte(key), data)
+	})
+}
+
+func (b *boltStore) Close() error {
+	return b.db.Close()
+}
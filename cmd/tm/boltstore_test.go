@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Regression test for the same double-delivery race sqliteStore was
+// fixed for: two concurrent PopOldest callers must never both win the
+// same pending item.
+func TestBoltStorePopOldestDoesNotDoubleDeliver(t *testing.T) {
+	store, err := newBoltStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		if err := store.Put(QueueItem{ID: fmt.Sprintf("item-%03d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		delivered = make(map[string]int)
+	)
+	popper := func() {
+		defer wg.Done()
+		for {
+			item, err := store.PopOldest()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if item == nil {
+				return
+			}
+			mu.Lock()
+			delivered[item.ID]++
+			mu.Unlock()
+		}
+	}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go popper()
+	}
+	wg.Wait()
+
+	if len(delivered) != n {
+		t.Fatalf("expected %d distinct items delivered, got %d", n, len(delivered))
+	}
+	for id, count := range delivered {
+		if count != 1 {
+			t.Errorf("item %s delivered %d times, want 1", id, count)
+		}
+	}
+}
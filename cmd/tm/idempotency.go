@@ -0,0 +1,45 @@
+package main
+
+import "time"
+
+const (
+	// idempotencyTTL is how long a Idempotency-Key is remembered so a
+	// retried POST /queue with the same key returns the original id
+	// instead of enqueuing a duplicate.
+	idempotencyTTL = 24 * time.Hour
+
+	// maxRetries is how many times a /nack'd item is requeued before
+	// it's moved to the dead-letter list.
+	maxRetries = 5
+
+	backoffBase = 5 * time.Second
+	backoffMax  = 10 * time.Minute
+)
+
+// backoffDuration returns the exponential backoff before an item nacked
+// retryCount times (so far) becomes poppable again.
+func backoffDuration(retryCount int) time.Duration {
+	d := backoffBase
+	for i := 0; i < retryCount; i++ {
+		d *= 2
+		if d >= backoffMax {
+			return backoffMax
+		}
+	}
+	return d
+}
+
+func nowRFC3339() string {
+	return time.Now().Format(time.RFC3339Nano)
+}
+
+func parseRFC3339(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreSeenKeyDedupAndExpiry(t *testing.T) {
+	store := newMemoryStore()
+
+	if _, ok := store.SeenKey("k1"); ok {
+		t.Fatal("expected an unremembered key to be unseen")
+	}
+
+	if err := store.RememberKey("k1", "item-1"); err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := store.SeenKey("k1"); !ok || id != "item-1" {
+		t.Fatalf("SeenKey() = %q, %v; want \"item-1\", true", id, ok)
+	}
+
+	// Age the remembered key past idempotencyTTL without waiting for
+	// real time to pass.
+	store.mu.Lock()
+	store.seenKeys["k1"] = seenKeyEntry{id: "item-1", at: time.Now().Add(-idempotencyTTL - time.Minute)}
+	store.mu.Unlock()
+
+	if _, ok := store.SeenKey("k1"); ok {
+		t.Fatal("expected a key older than idempotencyTTL to be treated as unseen")
+	}
+}
+
+// Regression test for a race where two concurrent POST /queue requests
+// carrying the same Idempotency-Key could both pass SeenKey before
+// either had called RememberKey, enqueuing the item twice.
+func TestHandleQueueConcurrentIdempotencyKeyEnqueuesOnce(t *testing.T) {
+	srv := &Server{
+		store:  newMemoryStore(),
+		tokens: newDevTokenStore("test-token"),
+	}
+
+	const n = 30
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/queue", strings.NewReader(`{"content":"hi"}`))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("Idempotency-Key", "dup-key")
+			rec := httptest.NewRecorder()
+			srv.handleQueue(rec, req)
+		}()
+	}
+	wg.Wait()
+
+	items, err := srv.store.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d queued item(s), want 1 for %d concurrent POSTs sharing an Idempotency-Key", len(items), n)
+	}
+}
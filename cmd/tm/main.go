@@ -1,14 +1,16 @@
 // tm - Thymer queue CLI
 //
 // Usage:
-//   cat README.md | tm              Push markdown to queue (action: append)
-//   echo "Meeting notes" | tm       Push to queue
-//   tm lifelog Had coffee           Push lifelog entry
-//   tm --collection "Tasks" < x.md  Push with collection target
-//   tm serve                        Run local server (same API as Cloudflare Worker)
+//
+//	cat README.md | tm              Push markdown to queue (action: append)
+//	echo "Meeting notes" | tm       Push to queue
+//	tm lifelog Had coffee           Push lifelog entry
+//	tm --collection "Tasks" < x.md  Push with collection target
+//	tm serve                        Run local server (same API as Cloudflare Worker)
 //
 // Config: Set THYMER_URL and THYMER_TOKEN environment variables
-//         or create ~/.config/tm/config with url= and token= lines
+//
+//	or create ~/.config/tm/config with url= and token= lines
 package main
 
 import (
@@ -19,10 +21,12 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -36,12 +40,20 @@ type Config struct {
 }
 
 type QueueItem struct {
-	ID         string `json:"id"`
-	Content    string `json:"content"`
-	Action     string `json:"action,omitempty"`
-	Collection string `json:"collection,omitempty"`
-	Title      string `json:"title,omitempty"`
-	CreatedAt  string `json:"createdAt"`
+	ID             string `json:"id"`
+	Content        string `json:"content"`
+	Action         string `json:"action,omitempty"`
+	Collection     string `json:"collection,omitempty"`
+	Title          string `json:"title,omitempty"`
+	CreatedAt      string `json:"createdAt"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+
+	// Delivery tracking, set by the store once at-least-once
+	// semantics come into play.
+	DeliveredAt string `json:"deliveredAt,omitempty"`
+	RetryCount  int    `json:"retryCount,omitempty"`
+	NotBefore   string `json:"notBefore,omitempty"`
+	State       string `json:"state,omitempty"` // pending | inflight | dead, set by Peek
 }
 
 func main() {
@@ -53,8 +65,24 @@ func main() {
 		return
 	}
 
+	// Token management
+	if len(args) > 0 && args[0] == "token" {
+		runTokenCommand(args[1:])
+		return
+	}
+
 	config := loadConfig()
 
+	// Upload mode
+	if len(args) > 0 && args[0] == "upload" {
+		if config.URL == "" || config.Token == "" {
+			fmt.Fprintln(os.Stderr, "Error: THYMER_URL and THYMER_TOKEN required")
+			os.Exit(1)
+		}
+		runUploadCommand(config, args[1:])
+		return
+	}
+
 	if config.URL == "" || config.Token == "" {
 		fmt.Fprintln(os.Stderr, "Error: THYMER_URL and THYMER_TOKEN required")
 		fmt.Fprintln(os.Stderr, "Set environment variables or create ~/.config/tm/config")
@@ -65,6 +93,7 @@ func main() {
 
 	// Parse arguments
 	req := QueueItem{Action: "append"}
+	var transforms []string
 
 	// Parse flags
 	i := 0
@@ -88,6 +117,18 @@ func main() {
 				i += 2
 				continue
 			}
+		case "--idempotency-key":
+			if i+1 < len(args) {
+				req.IdempotencyKey = args[i+1]
+				i += 2
+				continue
+			}
+		case "--transform":
+			if i+1 < len(args) {
+				transforms = append(transforms, args[i+1])
+				i += 2
+				continue
+			}
 		case "lifelog":
 			req.Action = "lifelog"
 			// Rest of args become the content
@@ -116,6 +157,16 @@ func main() {
 				fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
 				os.Exit(1)
 			}
+
+			// Binary stdin (e.g. `cat photo.png | tm`) can't be a
+			// markdown body - route it through the upload path instead.
+			if !utf8.Valid(data) {
+				if err := uploadBytes(config, data, "stdin", "", req.Collection, req.Title); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			}
 			req.Content = string(data)
 		}
 	}
@@ -126,7 +177,7 @@ func main() {
 	}
 
 	// Send to queue
-	if err := sendToQueue(config, req); err != nil {
+	if err := sendToQueue(config, req, transforms); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -134,7 +185,7 @@ func main() {
 	fmt.Printf("✓ Queued %d bytes (%s)\n", len(req.Content), req.Action)
 }
 
-func sendToQueue(config Config, req QueueItem) error {
+func sendToQueue(config Config, req QueueItem, transforms []string) error {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return err
@@ -147,6 +198,12 @@ func sendToQueue(config Config, req QueueItem) error {
 
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+config.Token)
+	if req.IdempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", req.IdempotencyKey)
+	}
+	if len(transforms) > 0 {
+		httpReq.Header.Set("X-Transform-Pipeline", strings.Join(transforms, "|"))
+	}
 
 	resp, err := http.DefaultClient.Do(httpReq)
 	if err != nil {
@@ -167,22 +224,106 @@ func sendToQueue(config Config, req QueueItem) error {
 // ============================================================================
 
 type Server struct {
-	queue map[string]QueueItem
-	mu    sync.RWMutex
-	token string
+	store    QueueStore
+	tokens   *TokenStore
+	pipeline []transformSpec
+	blobDir  string
+
+	wsSubs map[*wsSubscriber]struct{}
+	wsMu   sync.RWMutex
+
+	// idempotencyMu serializes the SeenKey/Put/RememberKey sequence in
+	// handleQueue so two concurrent retries of the same Idempotency-Key
+	// can't both miss SeenKey and both get enqueued.
+	idempotencyMu sync.Mutex
 }
 
 func runServer() {
-	token := os.Getenv("THYMER_TOKEN")
-	if token == "" {
-		// Generate a simple token for local dev
-		token = "local-dev-token"
-		fmt.Printf("⚠️  No THYMER_TOKEN set, using: %s\n", token)
+	storeSpec := "memory"
+	migrateFrom := ""
+	tokensPath := ""
+	blobDir := defaultBlobDir()
+	args := os.Args[2:] // past "tm serve"
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--store":
+			if i+1 < len(args) {
+				storeSpec = args[i+1]
+				i++
+			}
+		case "--migrate-from":
+			if i+1 < len(args) {
+				migrateFrom = args[i+1]
+				i++
+			}
+		case "--tokens":
+			if i+1 < len(args) {
+				tokensPath = args[i+1]
+				i++
+			}
+		case "--blob-dir":
+			if i+1 < len(args) {
+				blobDir = args[i+1]
+				i++
+			}
+		}
+	}
+
+	var tokens *TokenStore
+	if tokensPath == "" {
+		tokensPath = defaultTokensPath()
+	}
+	if _, err := os.Stat(tokensPath); err == nil {
+		ts, err := loadTokenStore(tokensPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading --tokens file: %v\n", err)
+			os.Exit(1)
+		}
+		tokens = ts
+		fmt.Printf("🔑 Loaded %d token(s) from %s\n", len(tokens.list()), tokensPath)
+	} else {
+		token := os.Getenv("THYMER_TOKEN")
+		if token == "" {
+			token = "local-dev-token"
+			fmt.Printf("⚠️  No THYMER_TOKEN set and no %s, using: %s\n", tokensPath, token)
+		}
+		tokens = newDevTokenStore(token)
+	}
+
+	store, err := openStore(storeSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if migrateFrom != "" {
+		oldStore, err := openStore(migrateFrom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening --migrate-from store: %v\n", err)
+			os.Exit(1)
+		}
+		n, err := migrateStore(oldStore, store)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error migrating queue: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("📦 Migrated %d item(s) from %s to %s\n", n, migrateFrom, storeSpec)
 	}
 
 	srv := &Server{
-		queue: make(map[string]QueueItem),
-		token: token,
+		store:    store,
+		tokens:   tokens,
+		pipeline: loadServerPipeline(),
+		blobDir:  blobDir,
+		wsSubs:   make(map[*wsSubscriber]struct{}),
+	}
+	srv.watchTokenReload()
+	if len(srv.pipeline) > 0 {
+		names := make([]string, len(srv.pipeline))
+		for i, s := range srv.pipeline {
+			names[i] = s.Name
+		}
+		fmt.Printf("🔧 Transform pipeline: %s\n", strings.Join(names, " -> "))
 	}
 
 	mux := http.NewServeMux()
@@ -191,18 +332,35 @@ func runServer() {
 	mux.HandleFunc("/stream", srv.handleStream)
 	mux.HandleFunc("/pending", srv.handlePending)
 	mux.HandleFunc("/peek", srv.handlePeek)
+	mux.HandleFunc("/ws", srv.handleWS)
+	mux.HandleFunc("/ack", srv.handleAck)
+	mux.HandleFunc("/nack", srv.handleNack)
+	mux.HandleFunc("/upload", srv.handleUpload)
+	mux.HandleFunc("/blob/", srv.handleBlob)
+	mux.Handle("/metrics", promhttp.Handler())
 
 	fmt.Printf("🪄 Thymer queue server on http://localhost:%s\n", LocalServerPort)
-	fmt.Printf("   Token: %s\n", token)
+	if tokens.path != "" {
+		fmt.Printf("   Tokens: %s (%d loaded, SIGHUP to reload)\n", tokensPath, len(tokens.list()))
+	} else {
+		fmt.Printf("   Tokens: dev token only (no %s)\n", tokensPath)
+	}
 	fmt.Println()
 	fmt.Println("   POST /queue   - Add to queue")
+	fmt.Println("   GET  /ws      - WebSocket push (sub-100ms, ack'd)")
 	fmt.Println("   GET  /stream  - SSE stream")
 	fmt.Println("   GET  /pending - Poll (legacy)")
 	fmt.Println("   GET  /peek    - View queue")
+	fmt.Println("   GET  /metrics - Prometheus metrics")
+	fmt.Println("   POST /ack     - Confirm an item was applied")
+	fmt.Println("   POST /nack    - Requeue an item (bounded retries, then dead-letter)")
+	fmt.Println("   POST /upload  - Upload an attachment")
+	fmt.Println("   GET  /blob/{sha256} - Fetch an uploaded attachment")
 	fmt.Println()
 	fmt.Println("   Ctrl+C to stop")
 
-	if err := http.ListenAndServe(":"+LocalServerPort, srv.corsMiddleware(mux)); err != nil {
+	handler := srv.corsMiddleware(srv.loggingMiddleware(mux))
+	if err := http.ListenAndServe(":"+LocalServerPort, handler); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -223,14 +381,31 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func (s *Server) checkAuth(r *http.Request) bool {
-	// Auth via header or query param
+// tokenFromRequest extracts the bearer token from the Authorization
+// header or, failing that, a ?token= query param.
+func tokenFromRequest(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	token := strings.TrimPrefix(authHeader, "Bearer ")
 	if token == "" {
 		token = r.URL.Query().Get("token")
 	}
-	return token == s.token
+	return token
+}
+
+// checkAuth reports whether the request carries any valid, unexpired
+// token, without regard to scope. Used where an action isn't scope-gated.
+func (s *Server) checkAuth(r *http.Request) bool {
+	_, ok := s.tokens.lookup(tokenFromRequest(r))
+	return ok
+}
+
+// authorize reports whether the request's token is valid and carries scope.
+func (s *Server) authorize(r *http.Request, scope string) (TokenEntry, bool) {
+	entry, ok := s.tokens.lookup(tokenFromRequest(r))
+	if !ok || !entry.hasScope(scope) {
+		return TokenEntry{}, false
+	}
+	return entry, true
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -244,7 +419,8 @@ func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !s.checkAuth(r) {
+	entry, ok := s.authorize(r, ScopeQueueWrite)
+	if !ok {
 		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
@@ -260,13 +436,56 @@ func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !entry.allowsCollection(req.Collection) {
+		http.Error(w, `{"error":"token not authorized for this collection"}`, http.StatusForbidden)
+		return
+	}
+
+	pipeline := s.pipeline
+	if header := r.Header.Get("X-Transform-Pipeline"); header != "" {
+		pipeline = allowedRequestPipeline(parseTransformPipeline(header), s.pipeline)
+	}
+	if len(pipeline) > 0 {
+		var err error
+		req, err = runTransforms(req, pipeline)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+	if req.IdempotencyKey != "" {
+		// Hold the lock across SeenKey/Put/RememberKey below so two
+		// concurrent retries of the same key can't both pass the
+		// SeenKey check before either has called RememberKey.
+		s.idempotencyMu.Lock()
+		defer s.idempotencyMu.Unlock()
+
+		if existingID, seen := s.store.SeenKey(req.IdempotencyKey); seen {
+			fmt.Printf("📥 Duplicate idempotency key, returning existing id %s\n", existingID)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "id": existingID, "duplicate": true})
+			return
+		}
+	}
+
 	// Generate ID with timestamp for ordering
 	req.ID = fmt.Sprintf("%d-%d", time.Now().UnixNano(), time.Now().UnixNano()%1000)
 	req.CreatedAt = time.Now().Format(time.RFC3339)
 
-	s.mu.Lock()
-	s.queue[req.ID] = req
-	s.mu.Unlock()
+	if err := s.store.Put(req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if req.IdempotencyKey != "" {
+		s.store.RememberKey(req.IdempotencyKey, req.ID)
+	}
+	s.notifySubscribers()
+	metrics.itemsQueued.WithLabelValues(req.Action).Inc()
+	s.updateQueueDepth()
 
 	fmt.Printf("📥 Queued: %s (%d bytes)\n", req.Action, len(req.Content))
 
@@ -274,8 +493,67 @@ func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "id": req.ID})
 }
 
+func (s *Server) handleAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := s.authorize(r, ScopeQueueRead); !ok {
+		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		http.Error(w, `{"error":"id required"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.Ack(req.ID); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	s.updateQueueDepth()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// handleNack requeues an item a client failed to apply, with
+// exponential backoff, up to maxRetries before it's moved to the
+// dead-letter list visible via /peek?state=dead.
+func (s *Server) handleNack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := s.authorize(r, ScopeQueueRead); !ok {
+		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		http.Error(w, `{"error":"id required"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.Nack(req.ID); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	s.updateQueueDepth()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
 func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
-	if !s.checkAuth(r) {
+	if _, ok := s.authorize(r, ScopeStream); !ok {
 		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
@@ -295,6 +573,8 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	flusher.Flush()
 
 	fmt.Println("📡 SSE client connected")
+	metrics.sseClients.Inc()
+	defer metrics.sseClients.Dec()
 
 	// Check queue every 2 seconds for 25 seconds
 	ticker := time.NewTicker(2 * time.Second)
@@ -307,6 +587,8 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 		case <-ticker.C:
 			item := s.popOldest()
 			if item != nil {
+				s.store.Ack(item.ID) // no ack channel over SSE, deliver at-most-once
+				s.updateQueueDepth()
 				data, _ := json.Marshal(item)
 				fmt.Fprintf(w, "data: %s\n\n", data)
 				fmt.Printf("📤 Sent: %s (%d bytes)\n", item.Action, len(item.Content))
@@ -327,7 +609,7 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handlePending(w http.ResponseWriter, r *http.Request) {
-	if !s.checkAuth(r) {
+	if _, ok := s.authorize(r, ScopeQueueRead); !ok {
 		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
@@ -337,6 +619,8 @@ func (s *Server) handlePending(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
+	s.store.Ack(item.ID) // no ack channel over plain polling, deliver at-most-once
+	s.updateQueueDepth()
 
 	fmt.Printf("📤 Sent (poll): %s (%d bytes)\n", item.Action, len(item.Content))
 
@@ -345,22 +629,26 @@ func (s *Server) handlePending(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handlePeek(w http.ResponseWriter, r *http.Request) {
-	if !s.checkAuth(r) {
+	if _, ok := s.authorize(r, ScopePeek); !ok {
 		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
 
-	s.mu.RLock()
-	items := make([]QueueItem, 0, len(s.queue))
-	for _, item := range s.queue {
-		items = append(items, item)
+	items, err := s.store.Peek()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusInternalServerError)
+		return
 	}
-	s.mu.RUnlock()
 
-	// Sort by ID (timestamp-based)
-	sort.Slice(items, func(i, j int) bool {
-		return items[i].ID < items[j].ID
-	})
+	if state := r.URL.Query().Get("state"); state != "" {
+		filtered := items[:0:0]
+		for _, item := range items {
+			if item.State == state {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -370,24 +658,14 @@ func (s *Server) handlePeek(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) popOldest() *QueueItem {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if len(s.queue) == 0 {
+	start := time.Now()
+	item, err := s.store.PopOldest()
+	metrics.popLatency.Observe(time.Since(start).Seconds())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error popping queue: %v\n", err)
 		return nil
 	}
-
-	// Find oldest by ID
-	var oldestID string
-	for id := range s.queue {
-		if oldestID == "" || id < oldestID {
-			oldestID = id
-		}
-	}
-
-	item := s.queue[oldestID]
-	delete(s.queue, oldestID)
-	return &item
+	return item
 }
 
 // ============================================================================
@@ -429,8 +707,12 @@ func printUsage() {
 	fmt.Println("  echo 'note' | tm                    Push text to Thymer")
 	fmt.Println("  tm lifelog Had coffee with Alex     Push lifelog entry")
 	fmt.Println("  tm --collection 'Tasks' < todo.md   Push to specific collection")
+	fmt.Println("  tm --idempotency-key abc < x.md     Safe to retry; won't double-queue")
+	fmt.Println("  tm --transform redact < x.md        Run one-off transforms before queueing")
 	fmt.Println("  tm create --title 'New Note'        Create new record")
+	fmt.Println("  tm upload file.png --caption 'pic'  Upload an attachment")
 	fmt.Println("  tm serve                            Run local queue server")
+	fmt.Println("  tm token add/list/revoke             Manage tokens for a shared server")
 	fmt.Println()
 	fmt.Println("Actions:")
 	fmt.Println("  append (default)  Append to daily page")
@@ -440,6 +722,14 @@ func printUsage() {
 	fmt.Println("Server mode:")
 	fmt.Printf("  tm serve                            Start server on port %s\n", LocalServerPort)
 	fmt.Println("                                      Same API as Cloudflare Worker")
+	fmt.Println("  tm serve --store bolt:/a/b.db       Persist the queue across restarts")
+	fmt.Println("  tm serve --store sqlite:/a.db       ...or back it with sqlite instead")
+	fmt.Println("  tm serve --store bolt:/new.db --migrate-from bolt:/old.db")
+	fmt.Println("                                      Drain an old store into the new one")
+	fmt.Println("  tm serve --tokens ~/.config/tm/tokens.json")
+	fmt.Println("                                      Multi-tenant tokens (default path shown)")
+	fmt.Println("  tm serve --blob-dir ~/.config/tm/blobs")
+	fmt.Println("                                      Where uploaded attachments are stored")
 	fmt.Println()
 	fmt.Println("Config:")
 	fmt.Println("  Set THYMER_URL and THYMER_TOKEN environment variables")
@@ -450,4 +740,7 @@ func printUsage() {
 	fmt.Println("  For local development:")
 	fmt.Printf("    url=%s\n", LocalServerURL)
 	fmt.Println("    token=local-dev-token")
+	fmt.Println()
+	fmt.Println("  tm serve reads the same file for a default transform pipeline:")
+	fmt.Println("    transform=frontmatter|redact|htmlToMarkdown")
 }
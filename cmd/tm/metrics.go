@@ -0,0 +1,73 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// metrics holds the Prometheus collectors for tm serve. It's a package
+// global (rather than a Server field) since prometheus.MustRegister
+// panics on double-registration and tm only ever runs one server per
+// process.
+var metrics = struct {
+	itemsQueued     *prometheus.CounterVec
+	queueDepth      prometheus.Gauge
+	deadLetterDepth prometheus.Gauge
+	sseClients      prometheus.Gauge
+	popLatency      prometheus.Histogram
+	httpRequests    *prometheus.CounterVec
+}{
+	itemsQueued: promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tm_items_queued_total",
+		Help: "Number of items queued, by action.",
+	}, []string{"action"}),
+
+	queueDepth: promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "tm_queue_depth",
+		Help: "Number of items currently pending or in-flight, excluding dead-lettered items.",
+	}),
+
+	deadLetterDepth: promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "tm_dead_letter_depth",
+		Help: "Number of items that exhausted their retries and landed in the dead-letter list.",
+	}),
+
+	sseClients: promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "tm_sse_clients",
+		Help: "Number of connected SSE clients.",
+	}),
+
+	popLatency: promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tm_pop_latency_seconds",
+		Help:    "Time spent popping an item off the queue store.",
+		Buckets: prometheus.DefBuckets,
+	}),
+
+	httpRequests: promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tm_http_requests_total",
+		Help: "HTTP requests by endpoint and status code.",
+	}, []string{"endpoint", "status"}),
+}
+
+// updateQueueDepth re-reads the store and publishes how many items are
+// pending or in-flight. It's called after every mutation rather than
+// incremented/decremented piecemeal, since Peek already has to walk
+// every state. Dead-lettered items are excluded so a backlog of items
+// that have already exhausted their retries doesn't mask (or fake) a
+// stuck live queue - see tm_dead_letter_depth for those.
+func (s *Server) updateQueueDepth() {
+	items, err := s.store.Peek()
+	if err != nil {
+		return
+	}
+	var depth, dead int
+	for _, item := range items {
+		if item.State == "dead" {
+			dead++
+		} else {
+			depth++
+		}
+	}
+	metrics.queueDepth.Set(float64(depth))
+	metrics.deadLetterDepth.Set(float64(dead))
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteStore is an alternative persistent backend for operators who'd
+// rather inspect the queue with a SQL client than a bbolt dump.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// database/sql pools connections, but a single sqlite file only
+	// tolerates one writer at a time; pin it to one connection so
+	// PopOldest's read-then-update below can't race against itself
+	// across two separate connections.
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS queue_items (
+			id TEXT PRIMARY KEY,
+			state TEXT NOT NULL, -- 'pending', 'inflight' or 'dead'
+			not_before TEXT NOT NULL DEFAULT '',
+			data TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS seen_keys (
+			key TEXT PRIMARY KEY,
+			item_id TEXT NOT NULL,
+			seen_at DATETIME NOT NULL
+		);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Put(item QueueItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO queue_items (id, state, not_before, data) VALUES (?, 'pending', ?, ?)`,
+		item.ID, item.NotBefore, data)
+	return err
+}
+
+func (s *sqliteStore) PopOldest() (*QueueItem, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Format(time.RFC3339Nano)
+	row := tx.QueryRow(`
+		SELECT id, data FROM queue_items
+		WHERE state = 'pending' AND (not_before = '' OR not_before <= ?)
+		ORDER BY id ASC LIMIT 1`, now)
+	var id string
+	var data []byte
+	if err := row.Scan(&id, &data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var item QueueItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	item.DeliveredAt = nowRFC3339()
+	data, err = json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE queue_items SET state = 'inflight', data = ? WHERE id = ? AND state = 'pending'`, data, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *sqliteStore) Peek() ([]QueueItem, error) {
+	rows, err := s.db.Query(`SELECT state, data FROM queue_items ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []QueueItem
+	for rows.Next() {
+		var state string
+		var data []byte
+		if err := rows.Scan(&state, &data); err != nil {
+			return nil, err
+		}
+		var item QueueItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, err
+		}
+		item.State = state
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, rows.Err()
+}
+
+func (s *sqliteStore) Ack(id string) error {
+	_, err := s.db.Exec(`DELETE FROM queue_items WHERE id = ? AND state = 'inflight'`, id)
+	return err
+}
+
+func (s *sqliteStore) Nack(id string) error {
+	row := s.db.QueryRow(`SELECT data FROM queue_items WHERE id = ? AND state = 'inflight'`, id)
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	var item QueueItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return err
+	}
+
+	item.RetryCount++
+	if item.RetryCount > maxRetries {
+		newData, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		_, err = s.db.Exec(`UPDATE queue_items SET state = 'dead', data = ? WHERE id = ?`, newData, id)
+		return err
+	}
+
+	item.NotBefore = time.Now().Add(backoffDuration(item.RetryCount)).Format(time.RFC3339Nano)
+	item.DeliveredAt = ""
+	newData, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`UPDATE queue_items SET state = 'pending', not_before = ?, data = ? WHERE id = ?`,
+		item.NotBefore, newData, id)
+	return err
+}
+
+// Requeue moves every row left in the inflight state back to pending.
+// Called once on open so an item stranded by the server itself dying
+// mid-delivery (not just a disconnecting consumer, which already goes
+// through Nack) is recovered instead of stuck forever.
+func (s *sqliteStore) Requeue() (int, error) {
+	rows, err := s.db.Query(`SELECT id, data FROM queue_items WHERE state = 'inflight'`)
+	if err != nil {
+		return 0, err
+	}
+	type stale struct {
+		id   string
+		data []byte
+	}
+	var items []stale
+	for rows.Next() {
+		var it stale
+		if err := rows.Scan(&it.id, &it.data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		items = append(items, it)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, it := range items {
+		var item QueueItem
+		if err := json.Unmarshal(it.data, &item); err != nil {
+			return n, err
+		}
+		item.DeliveredAt = ""
+		data, err := json.Marshal(item)
+		if err != nil {
+			return n, err
+		}
+		if _, err := s.db.Exec(`UPDATE queue_items SET state = 'pending', data = ? WHERE id = ? AND state = 'inflight'`, data, it.id); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *sqliteStore) PopDead() (*QueueItem, error) {
+	row := s.db.QueryRow(`SELECT id, data FROM queue_items WHERE state = 'dead' ORDER BY id ASC LIMIT 1`)
+	var id string
+	var data []byte
+	if err := row.Scan(&id, &data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var item QueueItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`DELETE FROM queue_items WHERE id = ? AND state = 'dead'`, id); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *sqliteStore) PutDead(item QueueItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO queue_items (id, state, not_before, data) VALUES (?, 'dead', ?, ?)`,
+		item.ID, item.NotBefore, data)
+	return err
+}
+
+func (s *sqliteStore) SeenKey(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	row := s.db.QueryRow(`SELECT item_id, seen_at FROM seen_keys WHERE key = ?`, key)
+	var id string
+	var seenAt time.Time
+	if err := row.Scan(&id, &seenAt); err != nil {
+		return "", false
+	}
+	if time.Since(seenAt) > idempotencyTTL {
+		return "", false
+	}
+	return id, true
+}
+
+func (s *sqliteStore) RememberKey(key, id string) error {
+	if key == "" {
+		return nil
+	}
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO seen_keys (key, item_id, seen_at) VALUES (?, ?, ?)`, key, id, time.Now())
+	return err
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Regression test for a race where two concurrent PopOldest callers
+// could both read the same pending row before either's UPDATE
+// committed, delivering the same item twice.
+func TestSQLiteStorePopOldestDoesNotDoubleDeliver(t *testing.T) {
+	store, err := newSQLiteStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		if err := store.Put(QueueItem{ID: fmt.Sprintf("item-%03d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		delivered = make(map[string]int)
+	)
+	popper := func() {
+		defer wg.Done()
+		for {
+			item, err := store.PopOldest()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if item == nil {
+				return
+			}
+			mu.Lock()
+			delivered[item.ID]++
+			mu.Unlock()
+		}
+	}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go popper()
+	}
+	wg.Wait()
+
+	if len(delivered) != n {
+		t.Fatalf("expected %d distinct items delivered, got %d", n, len(delivered))
+	}
+	for id, count := range delivered {
+		if count != 1 {
+			t.Errorf("item %s delivered %d times, want 1", id, count)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueueStore persists queued items so `tm serve` can survive restarts
+// without losing anything in flight. Pop and Ack are split in two so a
+// consumer can fetch an item and only have it removed once it confirms
+// delivery; if the consumer dies in between, the item is still
+// recoverable via Peek. The WebSocket consumer works this way; SSE and
+// /pending Ack each item as soon as they pop it, so their delivery is
+// at-most-once. Nack puts an item back for redelivery with exponential
+// backoff, up to maxRetries before it lands in the dead-letter list.
+// SeenKey/RememberKey back Idempotency-Key deduplication. Requeue
+// exists for crash recovery: an item left "inflight" because the
+// server itself died mid-delivery (not just a consumer) would
+// otherwise be stuck there forever. PopDead/PutDead let migrateStore
+// move dead-lettered items between backends.
+type QueueStore interface {
+	Put(item QueueItem) error
+	PopOldest() (*QueueItem, error)
+	Peek() ([]QueueItem, error)
+	Ack(id string) error
+	Nack(id string) error
+	SeenKey(key string) (id string, ok bool)
+	RememberKey(key, id string) error
+
+	// Requeue moves every item left "inflight" back to "pending". It's
+	// called once when a persistent store is opened, so items stranded
+	// by a crash or restart (rather than a cleanly disconnecting
+	// consumer, which already goes through Nack) become poppable again.
+	Requeue() (n int, err error)
+
+	// PopDead and PutDead move a dead-lettered item out of and into a
+	// store without resurrecting it as pending, so migrateStore can
+	// carry dead-letter entries across --store backends unchanged.
+	PopDead() (*QueueItem, error)
+	PutDead(item QueueItem) error
+}
+
+// openStore parses a --store flag value into a QueueStore. Persistent
+// backends have any items left "inflight" by a prior crash or restart
+// requeued as "pending" before the store is handed back, so a killed
+// `tm serve` doesn't strand them.
+//
+//	memory        in-memory, lost on restart (default)
+//	bolt:/a/b.db  embedded bbolt database at the given path
+//	sqlite:/a.db  embedded sqlite database at the given path
+func openStore(spec string) (QueueStore, error) {
+	var store QueueStore
+	var err error
+	switch {
+	case spec == "" || spec == "memory":
+		store = newMemoryStore()
+	default:
+		if path, ok := strings.CutPrefix(spec, "bolt:"); ok {
+			store, err = newBoltStore(path)
+		} else if path, ok := strings.CutPrefix(spec, "sqlite:"); ok {
+			store, err = newSQLiteStore(path)
+		} else {
+			return nil, fmt.Errorf("unknown --store %q (want memory, bolt:/path, or sqlite:/path)", spec)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := store.Requeue()
+	if err != nil {
+		return nil, fmt.Errorf("requeuing stale in-flight items: %w", err)
+	}
+	if n > 0 {
+		fmt.Printf("♻️  Requeued %d stale in-flight item(s) left over from a previous run\n", n)
+	}
+	return store, nil
+}
+
+// migrateStore drains every item in from into to, pending/inflight
+// first (inflight items were already requeued to pending by openStore)
+// and then dead-letter entries. Pending items still inside a Nack
+// backoff window (NotBefore in the future) are not popped by
+// PopOldest, so they are not migrated and stay in from.
+func migrateStore(from, to QueueStore) (int, error) {
+	n := 0
+	for {
+		item, err := from.PopOldest()
+		if err != nil {
+			return n, err
+		}
+		if item == nil {
+			break
+		}
+		if err := to.Put(*item); err != nil {
+			return n, err
+		}
+		if err := from.Ack(item.ID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	for {
+		item, err := from.PopDead()
+		if err != nil {
+			return n, err
+		}
+		if item == nil {
+			return n, nil
+		}
+		if err := to.PutDead(*item); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// memoryStore is the original in-memory queue, now behind QueueStore
+// so it can be swapped for a persistent backend.
+type memoryStore struct {
+	mu       sync.Mutex
+	pending  []QueueItem
+	inFlight map[string]QueueItem
+	dead     map[string]QueueItem
+	seenKeys map[string]seenKeyEntry
+}
+
+type seenKeyEntry struct {
+	id string
+	at time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		inFlight: make(map[string]QueueItem),
+		dead:     make(map[string]QueueItem),
+		seenKeys: make(map[string]seenKeyEntry),
+	}
+}
+
+func (m *memoryStore) Put(item QueueItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = append(m.pending, item)
+	sort.Slice(m.pending, func(i, j int) bool { return m.pending[i].ID < m.pending[j].ID })
+	return nil
+}
+
+func (m *memoryStore) PopOldest() (*QueueItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for i, item := range m.pending {
+		if notBefore, ok := parseRFC3339(item.NotBefore); ok && now.Before(notBefore) {
+			continue
+		}
+		item.DeliveredAt = nowRFC3339()
+		m.pending = append(m.pending[:i], m.pending[i+1:]...)
+		m.inFlight[item.ID] = item
+		return &item, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryStore) Peek() ([]QueueItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := make([]QueueItem, 0, len(m.pending)+len(m.inFlight)+len(m.dead))
+	for _, item := range m.pending {
+		item.State = "pending"
+		items = append(items, item)
+	}
+	for _, item := range m.inFlight {
+		item.State = "inflight"
+		items = append(items, item)
+	}
+	for _, item := range m.dead {
+		item.State = "dead"
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, nil
+}
+
+func (m *memoryStore) Ack(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.inFlight, id)
+	return nil
+}
+
+// Requeue is a no-op for memoryStore: nothing survives process exit,
+// so there's never stale in-flight state to recover on "open". Kept to
+// satisfy QueueStore.
+func (m *memoryStore) Requeue() (int, error) {
+	return 0, nil
+}
+
+func (m *memoryStore) PopDead() (*QueueItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, item := range m.dead {
+		delete(m.dead, id)
+		return &item, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryStore) PutDead(item QueueItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dead[item.ID] = item
+	return nil
+}
+
+func (m *memoryStore) Nack(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.inFlight[id]
+	if !ok {
+		return nil
+	}
+	delete(m.inFlight, id)
+
+	item.RetryCount++
+	if item.RetryCount > maxRetries {
+		m.dead[item.ID] = item
+		return nil
+	}
+
+	item.NotBefore = time.Now().Add(backoffDuration(item.RetryCount)).Format(time.RFC3339Nano)
+	item.DeliveredAt = ""
+	m.pending = append(m.pending, item)
+	sort.Slice(m.pending, func(i, j int) bool { return m.pending[i].ID < m.pending[j].ID })
+	return nil
+}
+
+func (m *memoryStore) SeenKey(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.seenKeys[key]
+	if !ok || time.Since(entry.at) > idempotencyTTL {
+		return "", false
+	}
+	return entry.id, true
+}
+
+func (m *memoryStore) RememberKey(key, id string) error {
+	if key == "" {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seenKeys[key] = seenKeyEntry{id: id, at: time.Now()}
+	return nil
+}
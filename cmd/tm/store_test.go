@@ -0,0 +1,183 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// queueStoreBackends lists every QueueStore implementation so the
+// state-machine tests below run identically against each one.
+func queueStoreBackends(t *testing.T) map[string]QueueStore {
+	t.Helper()
+	bolt, err := newBoltStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bolt.Close() })
+
+	sqlite, err := newSQLiteStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+
+	return map[string]QueueStore{
+		"memory": newMemoryStore(),
+		"bolt":   bolt,
+		"sqlite": sqlite,
+	}
+}
+
+// TestNackDeadLettersAfterMaxRetries exercises the nack->dead-letter
+// transition (TestNackBackoffDelaysRedelivery covers backoff). Items are
+// Put directly with RetryCount already at maxRetries so the test
+// doesn't have to wait out real backoff durations to cross the
+// threshold.
+func TestNackDeadLettersAfterMaxRetries(t *testing.T) {
+	for name, store := range queueStoreBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.Put(QueueItem{ID: "a", RetryCount: maxRetries}); err != nil {
+				t.Fatal(err)
+			}
+			item, err := store.PopOldest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item == nil {
+				t.Fatal("expected to pop the item just put")
+			}
+
+			if err := store.Nack(item.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			items, err := store.Peek()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 1 || items[0].State != "dead" {
+				t.Fatalf("expected item dead-lettered after exceeding maxRetries, got %+v", items)
+			}
+		})
+	}
+}
+
+// TestNackBackoffDelaysRedelivery checks that a Nack below maxRetries
+// requeues the item with a NotBefore in the future rather than making
+// it immediately poppable again.
+func TestNackBackoffDelaysRedelivery(t *testing.T) {
+	for name, store := range queueStoreBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.Put(QueueItem{ID: "a"}); err != nil {
+				t.Fatal(err)
+			}
+			item, err := store.PopOldest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item == nil {
+				t.Fatal("expected to pop the item just put")
+			}
+
+			if err := store.Nack(item.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			again, err := store.PopOldest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != nil {
+				t.Fatalf("expected backoff to delay redelivery, got %+v", again)
+			}
+		})
+	}
+}
+
+// TestRequeueRecoversStaleInFlightItems is the crash-recovery
+// regression test: an item left "inflight" when a persistent store is
+// closed (simulating the server process dying mid-delivery) must be
+// requeued to "pending" - not stuck forever - the next time the store
+// is opened.
+func TestRequeueRecoversStaleInFlightItems(t *testing.T) {
+	backends := []struct {
+		name string
+		open func(path string) (QueueStore, error)
+	}{
+		{"bolt", func(path string) (QueueStore, error) { return newBoltStore(path) }},
+		{"sqlite", func(path string) (QueueStore, error) { return newSQLiteStore(path) }},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "queue.db")
+			store, err := backend.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := store.Put(QueueItem{ID: "a"}); err != nil {
+				t.Fatal(err)
+			}
+			if item, err := store.PopOldest(); err != nil {
+				t.Fatal(err)
+			} else if item == nil {
+				t.Fatal("expected to pop the item just put")
+			}
+
+			if err := store.(interface{ Close() error }).Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reopened, err := backend.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.(interface{ Close() error }).Close()
+
+			n, err := reopened.Requeue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 1 {
+				t.Fatalf("Requeue() = %d, want 1", n)
+			}
+
+			got, err := reopened.PopOldest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == nil || got.ID != "a" {
+				t.Fatalf("expected item 'a' to be poppable again after Requeue, got %+v", got)
+			}
+		})
+	}
+}
+
+// TestMigrateStoreCarriesDeadLetterItems makes sure switching --store
+// backends doesn't silently drop items that already exhausted their
+// retries.
+func TestMigrateStoreCarriesDeadLetterItems(t *testing.T) {
+	from := newMemoryStore()
+	to := newMemoryStore()
+
+	if err := from.PutDead(QueueItem{ID: "d1", RetryCount: maxRetries + 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := migrateStore(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("migrateStore() = %d, want 1", n)
+	}
+
+	items, err := to.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ID != "d1" || items[0].State != "dead" {
+		t.Fatalf("expected dead item carried over as dead, got %+v", items)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// runTokenCommand implements `tm token add/list/revoke`, which edit the
+// tokens file a running `tm serve --tokens ...` reads from. Send the
+// server SIGHUP afterwards to pick the change up; it does not re-read
+// the file on its own.
+func runTokenCommand(args []string) {
+	if len(args) == 0 {
+		printTokenUsage()
+		os.Exit(1)
+	}
+
+	path := defaultTokensPath()
+	sub, rest := args[0], args[1:]
+
+	// --tokens can appear anywhere after the subcommand
+	var filtered []string
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == "--tokens" && i+1 < len(rest) {
+			path = rest[i+1]
+			i++
+			continue
+		}
+		filtered = append(filtered, rest[i])
+	}
+	rest = filtered
+
+	ts := &TokenStore{path: path}
+	if _, err := os.Stat(path); err == nil {
+		if err := ts.Reload(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	switch sub {
+	case "add":
+		tokenAddCommand(ts, rest)
+	case "list":
+		tokenListCommand(ts)
+	case "revoke":
+		tokenRevokeCommand(ts, rest)
+	default:
+		printTokenUsage()
+		os.Exit(1)
+	}
+}
+
+func tokenAddCommand(ts *TokenStore, args []string) {
+	entry := TokenEntry{}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--token":
+			if i+1 < len(args) {
+				entry.Token = args[i+1]
+				i++
+			}
+		case "--subject":
+			if i+1 < len(args) {
+				entry.Subject = args[i+1]
+				i++
+			}
+		case "--scopes":
+			if i+1 < len(args) {
+				entry.Scopes = strings.Split(args[i+1], ",")
+				i++
+			}
+		case "--collections":
+			if i+1 < len(args) {
+				entry.Collections = strings.Split(args[i+1], ",")
+				i++
+			}
+		case "--expires":
+			if i+1 < len(args) {
+				t, err := time.Parse(time.RFC3339, args[i+1])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: --expires must be RFC3339 (e.g. 2026-12-31T00:00:00Z): %v\n", err)
+					os.Exit(1)
+				}
+				entry.ExpiresAt = &t
+				i++
+			}
+		}
+	}
+
+	if entry.Token == "" {
+		entry.Token = generateToken()
+	}
+	if entry.Subject == "" {
+		fmt.Fprintln(os.Stderr, "Error: --subject is required")
+		os.Exit(1)
+	}
+	if len(entry.Scopes) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: --scopes is required (e.g. queue:write,peek)")
+		os.Exit(1)
+	}
+
+	if err := ts.add(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Added token for %q (%s): %s\n", entry.Subject, strings.Join(entry.Scopes, ","), entry.Token)
+}
+
+func tokenListCommand(ts *TokenStore) {
+	entries := ts.list()
+	if len(entries) == 0 {
+		fmt.Println("No tokens configured.")
+		return
+	}
+	for _, e := range entries {
+		status := "active"
+		if e.expired() {
+			status = "expired"
+		}
+		fmt.Printf("%s  %-20s scopes=%-30s collections=%-20s %s\n",
+			e.Token, e.Subject, strings.Join(e.Scopes, ","), strings.Join(e.Collections, ","), status)
+	}
+}
+
+func tokenRevokeCommand(ts *TokenStore, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: tm token revoke <token>")
+		os.Exit(1)
+	}
+	if err := ts.revoke(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("✓ Revoked")
+}
+
+func generateToken() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%x", b)
+}
+
+func printTokenUsage() {
+	fmt.Println("tm token - manage tokens for a shared tm serve")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  tm token add --subject alice --scopes queue:write,peek [--token t] [--collections Tasks,Notes] [--expires RFC3339]")
+	fmt.Println("  tm token list")
+	fmt.Println("  tm token revoke <token>")
+	fmt.Println()
+	fmt.Println("  All subcommands accept --tokens <path> (default ~/.config/tm/tokens.json)")
+}
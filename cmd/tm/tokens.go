@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scopes gate what an action a token may perform.
+const (
+	ScopeQueueWrite = "queue:write"
+	ScopeQueueRead  = "queue:read"
+	ScopePeek       = "peek"
+	ScopeStream     = "stream"
+)
+
+// TokenEntry is one credential in the tokens file. Collections, when
+// non-empty, restricts which Collection a queue:write may target -
+// empty means no restriction.
+type TokenEntry struct {
+	Token       string     `json:"token" yaml:"token"`
+	Subject     string     `json:"subject" yaml:"subject"`
+	Scopes      []string   `json:"scopes" yaml:"scopes"`
+	Collections []string   `json:"collections,omitempty" yaml:"collections,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+}
+
+func (e TokenEntry) expired() bool {
+	return e.ExpiresAt != nil && time.Now().After(*e.ExpiresAt)
+}
+
+func (e TokenEntry) hasScope(scope string) bool {
+	for _, s := range e.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (e TokenEntry) allowsCollection(collection string) bool {
+	if len(e.Collections) == 0 || collection == "" {
+		return true
+	}
+	for _, c := range e.Collections {
+		if c == collection {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenStore holds every credential a Server will accept, loaded from
+// a JSON/YAML file (by extension) so a shared home server can hand out
+// separate, revocable tokens per device or script instead of one
+// shared secret. Safe for concurrent use; Reload swaps the whole table
+// so a SIGHUP doesn't race in-flight lookups.
+type TokenStore struct {
+	mu      sync.RWMutex
+	path    string
+	entries map[string]TokenEntry
+}
+
+// newDevTokenStore builds a single-entry store for local development
+// when no --tokens file is configured, carrying every scope.
+func newDevTokenStore(token string) *TokenStore {
+	return &TokenStore{
+		entries: map[string]TokenEntry{
+			token: {
+				Token:   token,
+				Subject: "local-dev",
+				Scopes:  []string{ScopeQueueWrite, ScopeQueueRead, ScopePeek, ScopeStream},
+			},
+		},
+	}
+}
+
+func loadTokenStore(path string) (*TokenStore, error) {
+	ts := &TokenStore{path: path}
+	if err := ts.Reload(); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// Reload re-reads the tokens file from disk. Called at startup and on
+// SIGHUP so credentials can be added/revoked without restarting the server.
+func (ts *TokenStore) Reload() error {
+	data, err := os.ReadFile(ts.path)
+	if os.IsNotExist(err) {
+		ts.mu.Lock()
+		ts.entries = map[string]TokenEntry{}
+		ts.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var list []TokenEntry
+	if strings.HasSuffix(ts.path, ".json") {
+		err = json.Unmarshal(data, &list)
+	} else {
+		err = yaml.Unmarshal(data, &list)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", ts.path, err)
+	}
+
+	entries := make(map[string]TokenEntry, len(list))
+	for _, e := range list {
+		entries[e.Token] = e
+	}
+
+	ts.mu.Lock()
+	ts.entries = entries
+	ts.mu.Unlock()
+	return nil
+}
+
+// lookup returns the entry for a token if it exists and hasn't expired.
+func (ts *TokenStore) lookup(token string) (TokenEntry, bool) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	e, ok := ts.entries[token]
+	if !ok || e.expired() {
+		return TokenEntry{}, false
+	}
+	return e, true
+}
+
+func (ts *TokenStore) list() []TokenEntry {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	out := make([]TokenEntry, 0, len(ts.entries))
+	for _, e := range ts.entries {
+		out = append(out, e)
+	}
+	return out
+}
+
+// add appends entry and atomically rewrites the tokens file.
+func (ts *TokenStore) add(entry TokenEntry) error {
+	ts.mu.Lock()
+	if ts.entries == nil {
+		ts.entries = map[string]TokenEntry{}
+	}
+	ts.entries[entry.Token] = entry
+	ts.mu.Unlock()
+	return ts.save()
+}
+
+// revoke removes a token and atomically rewrites the tokens file.
+func (ts *TokenStore) revoke(token string) error {
+	ts.mu.Lock()
+	_, existed := ts.entries[token]
+	delete(ts.entries, token)
+	ts.mu.Unlock()
+	if !existed {
+		return fmt.Errorf("no such token")
+	}
+	return ts.save()
+}
+
+// save writes the store to a temp file and renames it into place so a
+// concurrent reader never observes a half-written tokens file.
+func (ts *TokenStore) save() error {
+	ts.mu.RLock()
+	list := make([]TokenEntry, 0, len(ts.entries))
+	for _, e := range ts.entries {
+		list = append(list, e)
+	}
+	ts.mu.RUnlock()
+
+	var data []byte
+	var err error
+	if strings.HasSuffix(ts.path, ".json") {
+		data, err = json.MarshalIndent(list, "", "  ")
+	} else {
+		data, err = yaml.Marshal(list)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ts.path), 0700); err != nil {
+		return err
+	}
+	tmp := ts.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ts.path)
+}
+
+// watchTokenReload reloads the token store on SIGHUP, so `tm token
+// add/revoke` takes effect on a running `tm serve` without a restart.
+// A no-op if the server is using the in-memory dev token store.
+func (s *Server) watchTokenReload() {
+	if s.tokens.path == "" {
+		return
+	}
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if err := s.tokens.Reload(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reloading tokens: %v\n", err)
+				continue
+			}
+			fmt.Printf("🔑 Reloaded %d token(s) from %s\n", len(s.tokens.list()), s.tokens.path)
+		}
+	}()
+}
+
+func defaultTokensPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "tm", "tokens.json")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenEntryScopeExpiryCollection(t *testing.T) {
+	e := TokenEntry{Scopes: []string{ScopeQueueWrite, ScopePeek}, Collections: []string{"Tasks"}}
+
+	if !e.hasScope(ScopeQueueWrite) {
+		t.Error("expected hasScope(queue:write) to be true")
+	}
+	if e.hasScope(ScopeStream) {
+		t.Error("expected hasScope(stream) to be false")
+	}
+
+	if !e.allowsCollection("Tasks") {
+		t.Error("expected allowsCollection(Tasks) to be true")
+	}
+	if e.allowsCollection("Other") {
+		t.Error("expected allowsCollection(Other) to be false when Collections is restricted")
+	}
+	if !e.allowsCollection("") {
+		t.Error("expected an empty collection to always be allowed")
+	}
+
+	if e.expired() {
+		t.Error("expected a token with no ExpiresAt to never expire")
+	}
+
+	past := time.Now().Add(-time.Hour)
+	if (TokenEntry{ExpiresAt: &past}).expired() != true {
+		t.Error("expected a token with a past ExpiresAt to be expired")
+	}
+
+	future := time.Now().Add(time.Hour)
+	if (TokenEntry{ExpiresAt: &future}).expired() {
+		t.Error("expected a token with a future ExpiresAt to not be expired")
+	}
+}
+
+func TestTokenStoreAddRevokeReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	ts := &TokenStore{path: path}
+	if err := ts.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ts.lookup("tok"); ok {
+		t.Fatal("expected no token before add")
+	}
+
+	if err := ts.add(TokenEntry{Token: "tok", Subject: "alice", Scopes: []string{ScopeQueueWrite}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := ts.lookup("tok"); !ok || got.Subject != "alice" {
+		t.Fatalf("lookup after add = %+v, %v", got, ok)
+	}
+
+	// A second store pointed at the same file only sees the change
+	// after Reload, proving add's atomic rewrite actually persisted.
+	other := &TokenStore{path: path}
+	if err := other.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := other.lookup("tok"); !ok {
+		t.Fatal("expected reloaded store to see the added token")
+	}
+
+	if err := ts.revoke("tok"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ts.lookup("tok"); ok {
+		t.Fatal("expected token to be gone immediately after revoke")
+	}
+
+	if err := other.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := other.lookup("tok"); ok {
+		t.Fatal("expected reloaded store to see the revoked token gone")
+	}
+}
+
+func TestServerAuthorizeEnforcesScope(t *testing.T) {
+	tokens := &TokenStore{entries: map[string]TokenEntry{
+		"writer-token": {Token: "writer-token", Scopes: []string{ScopeQueueWrite}},
+	}}
+	srv := &Server{tokens: tokens}
+
+	req := httptest.NewRequest("POST", "/queue", nil)
+	req.Header.Set("Authorization", "Bearer writer-token")
+	if _, ok := srv.authorize(req, ScopeQueueWrite); !ok {
+		t.Error("expected writer-token to carry queue:write scope")
+	}
+	if _, ok := srv.authorize(req, ScopeStream); ok {
+		t.Error("expected writer-token to lack stream scope")
+	}
+
+	req.Header.Set("Authorization", "Bearer unknown-token")
+	if _, ok := srv.authorize(req, ScopeQueueWrite); ok {
+		t.Error("expected an unknown token to be rejected regardless of scope")
+	}
+}
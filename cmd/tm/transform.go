@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+)
+
+// Transformer runs over a QueueItem between decode and store, so a
+// pipeline can inject frontmatter, render templates, redact secrets,
+// or convert HTML to Markdown before anything lands in Thymer.
+type Transformer interface {
+	Name() string
+	Transform(item QueueItem, arg string) (QueueItem, error)
+}
+
+var builtinTransformers = map[string]Transformer{
+	"frontmatter":    frontmatterTransformer{},
+	"template":       templateTransformer{},
+	"redact":         redactTransformer{},
+	"htmlToMarkdown": htmlToMarkdownTransformer{},
+}
+
+// transformSpec is one pipeline stage: a transformer name plus its
+// optional argument, e.g. {Name: "redact", Arg: "sk-[a-zA-Z0-9]+"}.
+type transformSpec struct {
+	Name string
+	Arg  string
+}
+
+// parseTransformPipeline parses a pipeline spec of the form
+// "name[=arg]|name[=arg]|...", as found in ~/.config/tm/config's
+// transform= lines or the CLI's --transform flag / X-Transform-Pipeline header.
+// A literal pipe inside arg (e.g. a redact regex's alternation) is
+// written as "\|" so it isn't mistaken for a stage separator.
+func parseTransformPipeline(spec string) []transformSpec {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil
+	}
+	var specs []transformSpec
+	for _, stage := range splitPipelineStages(spec) {
+		name, arg, _ := strings.Cut(stage, "=")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		specs = append(specs, transformSpec{Name: name, Arg: arg})
+	}
+	return specs
+}
+
+// splitPipelineStages splits spec on "|", except where the pipe is
+// escaped as "\|", which is unescaped to a literal "|" in the returned
+// stage rather than treated as a separator.
+func splitPipelineStages(spec string) []string {
+	var stages []string
+	var b strings.Builder
+	for i := 0; i < len(spec); i++ {
+		switch {
+		case spec[i] == '\\' && i+1 < len(spec) && spec[i+1] == '|':
+			b.WriteByte('|')
+			i++
+		case spec[i] == '|':
+			stages = append(stages, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(spec[i])
+		}
+	}
+	stages = append(stages, b.String())
+	return stages
+}
+
+// loadServerPipeline reads the default transform pipeline from
+// ~/.config/tm/config's "transform=" line (pipe-separated stages). Per
+// -request --transform flags sent by the CLI replace this pipeline,
+// keeping only stages whose names it already lists (see
+// allowedRequestPipeline).
+func loadServerPipeline() []transformSpec {
+	home, _ := os.UserHomeDir()
+	data, err := os.ReadFile(filepath.Join(home, ".config", "tm", "config"))
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if spec, ok := strings.CutPrefix(line, "transform="); ok {
+			return parseTransformPipeline(spec)
+		}
+	}
+	return nil
+}
+
+// allowedRequestPipeline filters a client-supplied X-Transform-Pipeline
+// against the stage names the operator already opted into via the
+// server's configured pipeline. Without this, any queue:write token
+// could name an arbitrary stage (e.g. "template") regardless of
+// whether the operator ever enabled it, defeating the point of
+// configuring a pipeline at all. A requested stage not in configured
+// is dropped with a warning rather than rejecting the whole request,
+// matching runTransforms' tolerance for bad stage names.
+func allowedRequestPipeline(requested, configured []transformSpec) []transformSpec {
+	allowed := make(map[string]bool, len(configured))
+	for _, spec := range configured {
+		allowed[spec.Name] = true
+	}
+	var out []transformSpec
+	for _, spec := range requested {
+		if !allowed[spec.Name] {
+			fmt.Fprintf(os.Stderr, "⚠️  X-Transform-Pipeline requested %q, which the server hasn't enabled, skipping\n", spec.Name)
+			continue
+		}
+		out = append(out, spec)
+	}
+	return out
+}
+
+// runTransforms applies each stage in order, skipping unknown names so
+// a typo in config doesn't take the whole server down.
+func runTransforms(item QueueItem, specs []transformSpec) (QueueItem, error) {
+	for _, spec := range specs {
+		t, ok := builtinTransformers[spec.Name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "⚠️  unknown transformer %q, skipping\n", spec.Name)
+			continue
+		}
+		var err error
+		item, err = t.Transform(item, spec.Arg)
+		if err != nil {
+			return item, fmt.Errorf("transform %q: %w", spec.Name, err)
+		}
+	}
+	return item, nil
+}
+
+// frontmatterTransformer prepends a YAML frontmatter block built from
+// the item's Title/CreatedAt plus tags passed as a comma-separated arg.
+type frontmatterTransformer struct{}
+
+func (frontmatterTransformer) Name() string { return "frontmatter" }
+
+func (frontmatterTransformer) Transform(item QueueItem, arg string) (QueueItem, error) {
+	title := item.Title
+	if title == "" {
+		title = "Untitled"
+	}
+	date := item.CreatedAt
+	if date == "" {
+		date = time.Now().Format(time.RFC3339)
+	}
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %q\n", title)
+	fmt.Fprintf(&b, "date: %s\n", date)
+	if arg != "" {
+		fmt.Fprintf(&b, "tags: [%s]\n", arg)
+	}
+	b.WriteString("---\n\n")
+	b.WriteString(item.Content)
+
+	item.Content = b.String()
+	return item, nil
+}
+
+// templateTransformer runs item.Content as a Go text/template, with
+// env/date helpers so a pushed snippet can reference {{.Env "TM_TEMPLATE_FOO"}}
+// or {{.Date}} before it's stored.
+type templateTransformer struct{}
+
+func (templateTransformer) Name() string { return "template" }
+
+type templateData struct {
+	Title      string
+	Collection string
+	now        time.Time
+}
+
+// templateEnvPrefix is the only class of environment variable .Env can
+// read. Content reaching this transformer is client-controlled, so an
+// unrestricted os.Getenv would let any queue:write token read the
+// server process's entire environment (tokens, credentials, ...) back
+// out through a rendered item. An operator who wants a value exposed to
+// templates sets it under this prefix deliberately.
+const templateEnvPrefix = "TM_TEMPLATE_"
+
+func (d templateData) Env(key string) string {
+	if !strings.HasPrefix(key, templateEnvPrefix) {
+		return ""
+	}
+	return os.Getenv(key)
+}
+
+func (d templateData) Date() string { return d.now.Format("2006-01-02") }
+func (d templateData) Time() string { return d.now.Format(time.RFC3339) }
+
+func (templateTransformer) Transform(item QueueItem, arg string) (QueueItem, error) {
+	tmpl, err := template.New("content").Parse(item.Content)
+	if err != nil {
+		return item, err
+	}
+	var b strings.Builder
+	data := templateData{Title: item.Title, Collection: item.Collection, now: time.Now()}
+	if err := tmpl.Execute(&b, data); err != nil {
+		return item, err
+	}
+	item.Content = b.String()
+	return item, nil
+}
+
+// redactTransformer strips common secret shapes (API keys, bearer
+// tokens, AWS access keys) before content leaves the machine. arg, if
+// set, is an additional regex to redact.
+type redactTransformer struct{}
+
+func (redactTransformer) Name() string { return "redact" }
+
+var defaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[a-zA-Z0-9]{20,}`),
+	regexp.MustCompile(`(?i)bearer\s+[a-zA-Z0-9._-]{10,}`),
+	regexp.MustCompile(`AKIA[0-9A-Z]{16}`),
+}
+
+func (redactTransformer) Transform(item QueueItem, arg string) (QueueItem, error) {
+	patterns := defaultRedactPatterns
+	if arg != "" {
+		custom, err := regexp.Compile(arg)
+		if err != nil {
+			return item, fmt.Errorf("invalid redact pattern: %w", err)
+		}
+		patterns = append(append([]*regexp.Regexp{}, defaultRedactPatterns...), custom)
+	}
+	for _, re := range patterns {
+		item.Content = re.ReplaceAllString(item.Content, "[REDACTED]")
+	}
+	return item, nil
+}
+
+// htmlToMarkdownTransformer converts item.Content from HTML to
+// Markdown, so a browser bookmarklet can POST page HTML directly.
+type htmlToMarkdownTransformer struct{}
+
+func (htmlToMarkdownTransformer) Name() string { return "htmlToMarkdown" }
+
+func (htmlToMarkdownTransformer) Transform(item QueueItem, arg string) (QueueItem, error) {
+	converter := md.NewConverter("", true, nil)
+	markdown, err := converter.ConvertString(item.Content)
+	if err != nil {
+		return item, err
+	}
+	item.Content = markdown
+	return item, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTemplateTransformerEnvOnlyExposesAllowlistedPrefix(t *testing.T) {
+	os.Setenv("THYMER_TOKEN", "super-secret")
+	defer os.Unsetenv("THYMER_TOKEN")
+	os.Setenv("TM_TEMPLATE_GREETING", "hello")
+	defer os.Unsetenv("TM_TEMPLATE_GREETING")
+
+	item := QueueItem{Content: `{{.Env "THYMER_TOKEN"}} / {{.Env "TM_TEMPLATE_GREETING"}}`}
+	got, err := (templateTransformer{}).Transform(item, "")
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	if strings.Contains(got.Content, "super-secret") {
+		t.Fatalf("Env leaked an unallowlisted variable: %q", got.Content)
+	}
+	if !strings.Contains(got.Content, "hello") {
+		t.Fatalf("Env dropped an allowlisted variable: %q", got.Content)
+	}
+}
+
+func TestParseTransformPipelineMatchesDocumentedSyntax(t *testing.T) {
+	got := parseTransformPipeline("redact=sk-[a-zA-Z0-9]+|frontmatter|template")
+	want := []transformSpec{
+		{Name: "redact", Arg: "sk-[a-zA-Z0-9]+"},
+		{Name: "frontmatter", Arg: ""},
+		{Name: "template", Arg: ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d stages, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("stage %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAllowedRequestPipelineDropsStagesTheServerDidNotEnable(t *testing.T) {
+	configured := []transformSpec{{Name: "redact"}, {Name: "frontmatter"}}
+	requested := []transformSpec{{Name: "redact", Arg: "custom"}, {Name: "template"}}
+
+	got := allowedRequestPipeline(requested, configured)
+	want := []transformSpec{{Name: "redact", Arg: "custom"}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d stages, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("stage %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAllowedRequestPipelineEmptyConfiguredAllowsNothing(t *testing.T) {
+	got := allowedRequestPipeline([]transformSpec{{Name: "template"}}, nil)
+	if len(got) != 0 {
+		t.Fatalf("got %+v, want no stages since the server configured none", got)
+	}
+}
+
+func TestParseTransformPipelineUnescapesPipeInArg(t *testing.T) {
+	got := parseTransformPipeline(`redact=sk-[a-zA-Z0-9]+\|AKIA[0-9A-Z]{16}|frontmatter`)
+	want := []transformSpec{
+		{Name: "redact", Arg: "sk-[a-zA-Z0-9]+|AKIA[0-9A-Z]{16}"},
+		{Name: "frontmatter", Arg: ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d stages, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("stage %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
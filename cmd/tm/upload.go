@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func defaultBlobDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "tm", "blobs")
+}
+
+// handleUpload accepts a multipart/form-data "file" field (mirroring
+// the usual UploadHandler shape), stores it under blobDir keyed by its
+// content hash so re-uploading the same file doesn't write a second
+// copy, and enqueues a new QueueItem (on every upload) whose Content
+// links or embeds it.
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entry, ok := s.authorize(r, ScopeQueueWrite)
+	if !ok {
+		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, `{"error":"file field required"}`, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	collection := r.FormValue("collection")
+	if !entry.allowsCollection(collection) {
+		http.Error(w, `{"error":"token not authorized for this collection"}`, http.StatusForbidden)
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	ext := filepath.Ext(header.Filename)
+	blobName := hash + ext
+
+	if err := os.MkdirAll(s.blobDir, 0700); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	blobPath := filepath.Join(s.blobDir, blobName)
+	if _, err := os.Stat(blobPath); os.IsNotExist(err) {
+		if err := os.WriteFile(blobPath, data, 0600); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// multipart.Writer.CreateFormFile (the only path tm upload uses)
+	// hardcodes Content-Type: application/octet-stream regardless of
+	// filename, so the header is never a useful signal here - detect
+	// from the extension, falling back to sniffing the bytes.
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	caption := r.FormValue("caption")
+	label := caption
+	if label == "" {
+		label = header.Filename
+	}
+	blobURL := fmt.Sprintf("%s://%s/blob/%s", schemeOf(r), r.Host, blobName)
+
+	var content string
+	if strings.HasPrefix(contentType, "image/") {
+		content = fmt.Sprintf("![%s](%s)", label, blobURL)
+	} else {
+		content = fmt.Sprintf("[%s](%s)", label, blobURL)
+	}
+
+	item := QueueItem{
+		Content:    content,
+		Action:     "append",
+		Collection: collection,
+		Title:      r.FormValue("title"),
+		ID:         fmt.Sprintf("%d-%d", time.Now().UnixNano(), time.Now().UnixNano()%1000),
+		CreatedAt:  time.Now().Format(time.RFC3339),
+	}
+
+	if err := s.store.Put(item); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	s.notifySubscribers()
+	metrics.itemsQueued.WithLabelValues(item.Action).Inc()
+	s.updateQueueDepth()
+
+	fmt.Printf("📎 Uploaded %s (%d bytes) -> %s\n", header.Filename, len(data), blobName)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "id": item.ID, "url": blobURL})
+}
+
+// handleBlob serves a previously uploaded attachment by its stored
+// name (sha256, optionally with extension), so Thymer can render it.
+func (s *Server) handleBlob(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAuth(r) {
+		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/blob/")
+	if name == "" || strings.Contains(name, "/") || strings.Contains(name, "..") {
+		http.Error(w, `{"error":"invalid blob name"}`, http.StatusBadRequest)
+		return
+	}
+
+	path := filepath.Join(s.blobDir, name)
+	if ct := mime.TypeByExtension(filepath.Ext(name)); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	http.ServeFile(w, r, path)
+}
+
+func schemeOf(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// ============================================================================
+// CLI: tm upload
+// ============================================================================
+
+// runUploadCommand implements `tm upload file.png [--caption "..."]`.
+func runUploadCommand(config Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: tm upload <file> [--caption \"...\"] [--collection X] [--title X]")
+		os.Exit(1)
+	}
+
+	path := args[0]
+	var caption, collection, title string
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--caption":
+			if i+1 < len(args) {
+				caption = args[i+1]
+				i++
+			}
+		case "--collection", "-c":
+			if i+1 < len(args) {
+				collection = args[i+1]
+				i++
+			}
+		case "--title", "-t":
+			if i+1 < len(args) {
+				title = args[i+1]
+				i++
+			}
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	if err := uploadBytes(config, data, filepath.Base(path), caption, collection, title); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// uploadBytes POSTs data to the server's /upload endpoint as
+// multipart/form-data and reports the blob URL it was stored under.
+func uploadBytes(config Config, data []byte, filename, caption, collection, title string) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(data); err != nil {
+		return err
+	}
+	for field, value := range map[string]string{"caption": caption, "collection": collection, "title": title} {
+		if value != "" {
+			if err := writer.WriteField(field, value); err != nil {
+				return err
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest("POST", config.URL+"/upload", &body)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
+	httpReq.Header.Set("Authorization", "Bearer "+config.Token)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Uploaded %d bytes -> %s\n", len(data), result.URL)
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+)
+
+// A 1x1 transparent PNG, just enough for http.DetectContentType to
+// recognize "image/png".
+var tinyPNG = []byte{
+	0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a,
+	0x00, 0x00, 0x00, 0x0d, 0x49, 0x48, 0x44, 0x52,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+	0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4,
+	0x89, 0x00, 0x00, 0x00, 0x0a, 0x49, 0x44, 0x41,
+	0x54, 0x78, 0x9c, 0x63, 0x00, 0x01, 0x00, 0x00,
+	0x05, 0x00, 0x01, 0x0d, 0x0a, 0x2d, 0xb4, 0x00,
+	0x00, 0x00, 0x00, 0x49, 0x45, 0x4e, 0x44, 0xae,
+	0x42, 0x60, 0x82,
+}
+
+func TestHandleUploadDetectsImageContentTypeFromClientMultipart(t *testing.T) {
+	dir := t.TempDir()
+	srv := &Server{
+		store:   newMemoryStore(),
+		tokens:  newDevTokenStore("test-token"),
+		blobDir: dir,
+		wsSubs:  make(map[*wsSubscriber]struct{}),
+	}
+
+	// Build the multipart body the same way uploadBytes does, so the
+	// part's own Content-Type header is the stdlib's hardcoded
+	// application/octet-stream - the exact case that broke detection.
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(tinyPNG); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+
+	srv.handleUpload(rec, req)
+
+	if rec.Code != 200 {
+		t.Fatalf("handleUpload status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	items, err := srv.store.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("expected 1 queued item, got %d", len(items))
+	}
+	if got := items[0].Content; got == "" || got[0] != '!' {
+		t.Errorf("expected inline image markdown (![...]), got %q", got)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsUpgrader upgrades /ws connections. CORS is already handled by
+// corsMiddleware, so we accept any origin here.
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsAckFrame is sent by the client once it has durably applied an item.
+type wsAckFrame struct {
+	Ack string `json:"ack"`
+}
+
+// wsSubscriber wakes a handleWS connection up whenever something is
+// put on the queue (handleQueue and handleUpload call notifySubscribers); the connection then pops items itself so delivery
+// goes through the same PopOldest/Ack/Nack state machine as every
+// other consumer, instead of broadcasting the item payload directly.
+type wsSubscriber struct {
+	wake chan struct{}
+}
+
+func (s *Server) addSubscriber() *wsSubscriber {
+	sub := &wsSubscriber{wake: make(chan struct{}, 1)}
+	s.wsMu.Lock()
+	s.wsSubs[sub] = struct{}{}
+	s.wsMu.Unlock()
+	return sub
+}
+
+func (s *Server) removeSubscriber(sub *wsSubscriber) {
+	s.wsMu.Lock()
+	delete(s.wsSubs, sub)
+	s.wsMu.Unlock()
+}
+
+// notifySubscribers wakes every connected WebSocket client so it goes
+// and pops whatever was just queued. It never blocks on a slow
+// subscriber; if the wake is already pending, the item is still
+// picked up on the connection's next drain.
+func (s *Server) notifySubscribers() {
+	s.wsMu.RLock()
+	defer s.wsMu.RUnlock()
+	for sub := range s.wsSubs {
+		select {
+		case sub.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authorize(r, ScopeStream); !ok {
+		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Fprintf(w, "websocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	sub := s.addSubscriber()
+	defer s.removeSubscriber(sub)
+
+	fmt.Println("🔌 WebSocket client connected")
+
+	var writeMu sync.Mutex
+	var awaitingMu sync.Mutex
+	awaiting := make(map[string]struct{})
+	done := make(chan struct{})
+
+	// Reader goroutine: wait for ack frames and remove the
+	// corresponding item from the queue.
+	go func() {
+		defer close(done)
+		for {
+			var frame wsAckFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+			if frame.Ack != "" {
+				// store.Ack and the awaiting deletion must happen as one
+				// step under awaitingMu, so the teardown sweep below can
+				// never observe an id mid-way between the two and Nack
+				// something this goroutine just legitimately Ack'd.
+				awaitingMu.Lock()
+				s.store.Ack(frame.Ack)
+				delete(awaiting, frame.Ack)
+				awaitingMu.Unlock()
+				s.updateQueueDepth()
+			}
+		}
+	}()
+
+	// drain pops every item currently available and pushes it down
+	// the socket, tracking it as awaiting ack. Returns false if the
+	// connection died mid-write.
+	drain := func() bool {
+		for {
+			item := s.popOldest()
+			if item == nil {
+				return true
+			}
+			writeMu.Lock()
+			err := conn.WriteJSON(item)
+			writeMu.Unlock()
+			if err != nil {
+				fmt.Println("🔌 WebSocket client disconnected")
+				s.store.Nack(item.ID)
+				return false
+			}
+			awaitingMu.Lock()
+			awaiting[item.ID] = struct{}{}
+			awaitingMu.Unlock()
+			s.updateQueueDepth()
+			fmt.Printf("📤 Pushed (ws): %s (%d bytes)\n", item.Action, len(item.Content))
+		}
+	}
+
+	// Catch up on anything already queued before this connection
+	// subscribed, then wait for wake-ups from newly queued items.
+	if !drain() {
+		return
+	}
+loop:
+	for {
+		select {
+		case <-sub.wake:
+			if !drain() {
+				break loop
+			}
+
+		case <-done:
+			break loop
+
+		case <-r.Context().Done():
+			break loop
+		}
+	}
+
+	// Close the connection to unblock the reader goroutine's ReadJSON
+	// (if it's still running) and wait for it to actually exit, so the
+	// sweep below can't race an ack the reader is mid-way through
+	// processing.
+	conn.Close()
+	<-done
+
+	// Anything delivered to this connection but never acked goes
+	// back to pending for another consumer instead of being lost.
+	awaitingMu.Lock()
+	for id := range awaiting {
+		s.store.Nack(id)
+	}
+	awaitingMu.Unlock()
+	s.updateQueueDepth()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestHandleWSAckCloseRaceNeverDropsOrDuplicates is the regression test
+// for a race where the reader goroutine's store.Ack(frame.Ack) (and the
+// matching delete from awaiting) wasn't serialized against the
+// teardown sweep that Nacks everything still in awaiting once the
+// connection goes away. A client closing its tab right after sending
+// its last ack - exactly the "tab close mid-delivery" scenario this
+// request exists to cover - could have that ack race the sweep,
+// either dropping an already-applied item back onto the queue or
+// leaving an un-acked item stuck.
+func TestHandleWSAckCloseRaceNeverDropsOrDuplicates(t *testing.T) {
+	srv := &Server{
+		store:  newMemoryStore(),
+		tokens: newDevTokenStore("test-token"),
+		wsSubs: make(map[*wsSubscriber]struct{}),
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleWS))
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer test-token")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	const n = 5
+	for i := 0; i < n; i++ {
+		if err := srv.store.Put(QueueItem{ID: itemID(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv.notifySubscribers()
+
+	delivered := make(map[string]bool, n)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i := 0; i < n; i++ {
+		var item QueueItem
+		if err := conn.ReadJSON(&item); err != nil {
+			t.Fatalf("ReadJSON %d: %v", i, err)
+		}
+		if delivered[item.ID] {
+			t.Fatalf("item %s delivered twice", item.ID)
+		}
+		delivered[item.ID] = true
+	}
+
+	// Ack everything except the last item, then close immediately - no
+	// pause between the final WriteJSON and Close - to race the ack
+	// bookkeeping against handleWS's teardown sweep.
+	for i := 0; i < n-1; i++ {
+		if err := conn.WriteJSON(wsAckFrame{Ack: itemID(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+
+	// Poll for the server side to settle: the unacked item should land
+	// back in the store as pending, and nothing else should reappear.
+	deadline := time.Now().Add(2 * time.Second)
+	var items []QueueItem
+	for time.Now().Before(deadline) {
+		items, err = srv.store.Peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(items) != 1 {
+		t.Fatalf("got %d item(s) left in the store, want exactly 1 (the unacked one): %+v", len(items), items)
+	}
+	if got := items[0].ID; got != itemID(n-1) {
+		t.Errorf("leftover item = %q, want %q", got, itemID(n-1))
+	}
+	if got := items[0].State; got != "pending" {
+		t.Errorf("leftover item state = %q, want \"pending\" (requeued, not lost)", got)
+	}
+}
+
+func itemID(i int) string {
+	return "item-" + string(rune('0'+i))
+}